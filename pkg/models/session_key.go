@@ -0,0 +1,11 @@
+package models
+
+// SessionKey is the row stored by the "db" session provider. It lets web
+// sessions live in Grafana's own database instead of a separate session
+// store, so the same migrations, connection pool and credentials cover both.
+type SessionKey struct {
+	Id     int64
+	Key    string `xorm:"unique"`
+	Data   []byte
+	Expiry int64
+}
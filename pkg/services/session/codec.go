@@ -0,0 +1,199 @@
+// Copyright 2013 Beego Authors
+// Copyright 2014 The Macaron Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-macaron/session"
+)
+
+// Codec serializes and deserializes session data, so a provider's encoding
+// can be swapped without touching the store/provider code that reads and
+// writes rows.
+type Codec interface {
+	Encode(map[interface{}]interface{}) ([]byte, error)
+	Decode([]byte) (map[interface{}]interface{}, error)
+
+	// Tag identifies this codec in the one-byte tag encodeWithTag writes
+	// ahead of a row's payload, so decodeRow can recover the codec a row
+	// was written with even after Init selects a different one.
+	Tag() byte
+}
+
+// Tag values for the codec byte encodeWithTag prepends to a row. They must
+// never change once assigned, or existing rows become unreadable.
+const (
+	tagGob byte = iota + 1
+	tagJSON
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(kv map[interface{}]interface{}) ([]byte, error) {
+	return session.EncodeGob(kv)
+}
+
+func (gobCodec) Decode(data []byte) (map[interface{}]interface{}, error) {
+	return session.DecodeGob(data)
+}
+
+func (gobCodec) Tag() byte { return tagGob }
+
+// jsonCodec stores session data as a JSON object keyed by fmt.Sprintf("%v")
+// of the original key, so rows can be inspected and repaired with plain SQL
+// tools instead of requiring every custom value type to call gob.Register.
+// Values round-trip through encoding/json, so numeric types widen to
+// float64 on Decode; code that type-asserts Get() results to a specific
+// numeric type should keep the gob codec.
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(kv map[interface{}]interface{}) ([]byte, error) {
+	m := make(map[string]interface{}, len(kv))
+	for k, v := range kv {
+		m[fmt.Sprintf("%v", k)] = v
+	}
+	return json.Marshal(m)
+}
+
+func (jsonCodec) Decode(data []byte) (map[interface{}]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	kv := make(map[interface{}]interface{}, len(m))
+	for k, v := range m {
+		kv[k] = v
+	}
+	return kv, nil
+}
+
+func (jsonCodec) Tag() byte { return tagJSON }
+
+var codecs = map[string]Codec{
+	"gob":  gobCodec{},
+	"json": jsonCodec{},
+}
+
+var codecsByTag = map[byte]Codec{
+	tagGob:  gobCodec{},
+	tagJSON: jsonCodec{},
+}
+
+// parseCodec pulls the "codec" parameter out of connStr, if present, and
+// returns the matching Codec along with connStr stripped of that parameter
+// so the underlying sql driver never sees it. The default codec is gob.
+func parseCodec(connStr string) (Codec, string, error) {
+	idx := strings.IndexByte(connStr, '?')
+	if idx == -1 {
+		return codecs["gob"], connStr, nil
+	}
+
+	query, err := url.ParseQuery(connStr[idx+1:])
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := query.Get("codec")
+	if name == "" {
+		return codecs["gob"], connStr, nil
+	}
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, "", fmt.Errorf("session: unknown codec %q", name)
+	}
+
+	query.Del("codec")
+	rest := query.Encode()
+	if rest == "" {
+		return codec, connStr[:idx], nil
+	}
+	return codec, connStr[:idx] + "?" + rest, nil
+}
+
+// parseCodecParams parses a standalone "codec=name" parameter string, used
+// by providers that have no DSN of their own to carry the parameter (e.g.
+// DBProvider, which reuses Grafana's already-opened connection). The
+// default codec is gob.
+func parseCodecParams(params string) (Codec, error) {
+	if params == "" {
+		return codecs["gob"], nil
+	}
+
+	query, err := url.ParseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
+	name := query.Get("codec")
+	if name == "" {
+		return codecs["gob"], nil
+	}
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("session: unknown codec %q", name)
+	}
+
+	return codec, nil
+}
+
+// tagMarker prefixes a row written by encodeWithTag. encoding/gob never
+// produces a leading zero byte for a non-empty message, so it can't collide
+// with an untagged row left over from before this tagging scheme existed.
+const tagMarker = 0x00
+
+// encodeWithTag encodes kv with c and prepends tagMarker and c's Tag(), so
+// decodeRow can recover the exact codec a row was written with even after a
+// later Init selects a different default.
+func encodeWithTag(c Codec, kv map[interface{}]interface{}) ([]byte, error) {
+	data, err := c.Encode(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{tagMarker, c.Tag()}, data...), nil
+}
+
+// decodeRow decodes a session row. Rows written by encodeWithTag carry an
+// explicit codec tag and are decoded with the matching codec regardless of
+// what's configured now. Rows persisted before this tagging scheme existed
+// have no tag, so they're recovered on a best-effort basis instead: gob,
+// the long-standing default, is tried first, falling back to JSON on a
+// decode error. Which of the two is used for an untagged row rests on
+// whether gob decoding succeeds, not on the row's leading byte.
+func decodeRow(data []byte) (map[interface{}]interface{}, error) {
+	if len(data) == 0 {
+		return make(map[interface{}]interface{}), nil
+	}
+
+	if len(data) >= 2 && data[0] == tagMarker {
+		if c, ok := codecsByTag[data[1]]; ok {
+			return c.Decode(data[2:])
+		}
+	}
+
+	if kv, err := codecs["gob"].Decode(data); err == nil {
+		return kv, nil
+	}
+
+	return codecs["json"].Decode(data)
+}
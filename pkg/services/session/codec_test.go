@@ -0,0 +1,162 @@
+package session
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "gob", codec: gobCodec{}},
+		{name: "json", codec: jsonCodec{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := map[interface{}]interface{}{"uid": "42", "flag": "on"}
+
+			data, err := tt.codec.Encode(kv)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			got, err := tt.codec.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, kv) {
+				t.Fatalf("got %#v, want %#v", got, kv)
+			}
+		})
+	}
+}
+
+func TestDecodeRowPrefersTagOverSniffing(t *testing.T) {
+	kv := map[interface{}]interface{}{"uid": "42"}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := encodeWithTag(codec, kv)
+			if err != nil {
+				t.Fatalf("encodeWithTag: %v", err)
+			}
+
+			got, err := decodeRow(data)
+			if err != nil {
+				t.Fatalf("decodeRow: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, kv) {
+				t.Fatalf("got %#v, want %#v", got, kv)
+			}
+		})
+	}
+}
+
+func TestDecodeRowSniffsUntaggedLegacyRows(t *testing.T) {
+	kv := map[interface{}]interface{}{"uid": "42"}
+
+	// Rows written before codec tagging existed have no tagMarker prefix.
+	data, err := codecs["json"].Encode(kv)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	got, err := decodeRow(data)
+	if err != nil {
+		t.Fatalf("decodeRow: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, kv) {
+		t.Fatalf("got %#v, want %#v", got, kv)
+	}
+}
+
+func TestDecodeRowHandlesLegacyGobRowCollidingWithJSONSniffByte(t *testing.T) {
+	// A gob row starts with the length of its first message. Search for a
+	// payload whose first byte is '{' (0x7B == 123) and confirm it still
+	// decodes as gob rather than failing in jsonCodec.Decode. For a session
+	// map that first message is the map's type definition, whose length does
+	// not depend on the values, so varying the value does not change the
+	// first byte; when no payload in the range starts with '{', the test
+	// skips.
+	var data []byte
+	for i := 0; i < 256; i++ {
+		kv := map[interface{}]interface{}{"pad": strings.Repeat("a", i)}
+		encoded, err := codecs["gob"].Encode(kv)
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if encoded[0] == '{' {
+			data = encoded
+			break
+		}
+	}
+	if data == nil {
+		t.Skip("could not construct a gob payload whose first byte collides with '{' in the range searched")
+	}
+
+	kv, err := decodeRow(data)
+	if err != nil {
+		t.Fatalf("decodeRow misrouted a legacy gob row starting with '{': %v", err)
+	}
+
+	if len(kv) == 0 {
+		t.Fatal("expected decoded session data, got an empty map")
+	}
+}
+
+func TestDecodeRowEmpty(t *testing.T) {
+	kv, err := decodeRow(nil)
+	if err != nil {
+		t.Fatalf("decodeRow: %v", err)
+	}
+
+	if len(kv) != 0 {
+		t.Fatalf("expected empty map, got %#v", kv)
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	tests := []struct {
+		name        string
+		connStr     string
+		wantConnStr string
+		wantTag     byte
+		wantErr     bool
+	}{
+		{name: "no params defaults to gob", connStr: "user:pass@tcp(host)/db", wantConnStr: "user:pass@tcp(host)/db", wantTag: tagGob},
+		{name: "other params untouched", connStr: "user:pass@tcp(host)/db?parseTime=true", wantConnStr: "user:pass@tcp(host)/db?parseTime=true", wantTag: tagGob},
+		{name: "codec param selects json and is stripped", connStr: "user:pass@tcp(host)/db?codec=json", wantConnStr: "user:pass@tcp(host)/db", wantTag: tagJSON},
+		{name: "codec param stripped alongside others", connStr: "user:pass@tcp(host)/db?parseTime=true&codec=json", wantConnStr: "user:pass@tcp(host)/db?parseTime=true", wantTag: tagJSON},
+		{name: "unknown codec errors", connStr: "user:pass@tcp(host)/db?codec=msgpack", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, connStr, err := parseCodec(tt.connStr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCodec: %v", err)
+			}
+
+			if connStr != tt.wantConnStr {
+				t.Fatalf("connStr = %q, want %q", connStr, tt.wantConnStr)
+			}
+
+			if codec.Tag() != tt.wantTag {
+				t.Fatalf("codec tag = %v, want %v", codec.Tag(), tt.wantTag)
+			}
+		})
+	}
+}
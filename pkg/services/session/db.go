@@ -0,0 +1,255 @@
+// Copyright 2013 Beego Authors
+// Copyright 2014 The Macaron Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package session
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-macaron/session"
+	"github.com/go-xorm/builder"
+	"github.com/go-xorm/xorm"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// DBStore represents a session store that persists through Grafana's own
+// database layer rather than a dedicated session connection.
+type DBStore struct {
+	engine *xorm.Engine
+	codec  Codec
+	sid    string
+	lock   sync.RWMutex
+	data   map[interface{}]interface{}
+	expiry int64
+	dirty  bool
+}
+
+// NewDBStore creates and returns a db session store.
+func NewDBStore(engine *xorm.Engine, codec Codec, sid string, kv map[interface{}]interface{}, expiry int64) *DBStore {
+	return &DBStore{
+		engine: engine,
+		codec:  codec,
+		sid:    sid,
+		data:   kv,
+		expiry: expiry,
+		dirty:  false,
+	}
+}
+
+// Set sets value to given key in session.
+func (s *DBStore) Set(key, val interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data[key] = val
+	s.dirty = true
+	return nil
+}
+
+// Get gets value by given key in session.
+func (s *DBStore) Get(key interface{}) interface{} {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.data[key]
+}
+
+// Delete delete a key from session.
+func (s *DBStore) Delete(key interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.data, key)
+	s.dirty = true
+	return nil
+}
+
+// ID returns current session ID.
+func (s *DBStore) ID() string {
+	return s.sid
+}
+
+// Release releases resource and save data to provider.
+func (s *DBStore) Release() error {
+	newExpiry := time.Now().Unix()
+	if !s.dirty && (s.expiry+60) >= newExpiry {
+		return nil
+	}
+
+	data, err := encodeWithTag(s.codec, s.data)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.engine.Where(builder.Eq{"key": s.sid}).
+		Cols("data", "expiry").
+		Update(&models.SessionKey{Data: data, Expiry: newExpiry})
+	s.dirty = false
+	s.expiry = newExpiry
+	return err
+}
+
+// Flush deletes all session data.
+func (s *DBStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.data = make(map[interface{}]interface{})
+	s.dirty = true
+	return nil
+}
+
+// DBProvider represents a session provider implementation that stores
+// session rows through Grafana's ORM, so sessions share the same connection,
+// credentials and schema migrations as the rest of Grafana's data instead of
+// needing a dedicated mysql/postgres connection string. All reads and writes
+// go through the xorm engine; their conditions are built with its query
+// builder, except GC's expiry condition, which is a plain arithmetic SQL
+// expression, so the provider works unmodified on every engine Grafana
+// itself supports (MySQL, Postgres, SQLite, MSSQL).
+type DBProvider struct {
+	engine *xorm.Engine
+	codec  Codec
+	expire int64
+}
+
+// Init initializes db session provider. connStr carries only session
+// provider options (currently just "codec=gob|json"); the connection itself
+// always reuses the engine Grafana's sqlstore already opened and migrated.
+func (p *DBProvider) Init(expire int64, connStr string) (err error) {
+	p.expire = expire
+
+	p.codec, err = parseCodecParams(connStr)
+	if err != nil {
+		return err
+	}
+
+	p.engine = sqlstore.GetEngine()
+	return nil
+}
+
+// Read returns raw session store by session ID. A row whose expiry has
+// already passed is reset in place and a fresh, empty store is returned
+// instead of resuming the stale session, mirroring MysqlProvider.Read.
+func (p *DBProvider) Read(sid string) (session.RawStore, error) {
+	expiry := time.Now().Unix()
+
+	var row models.SessionKey
+	has, err := p.engine.Where(builder.Eq{"key": sid}).Get(&row)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch {
+	case !has:
+		row = models.SessionKey{Key: sid, Expiry: expiry}
+		if _, err = p.engine.Insert(&row); err != nil {
+			return nil, err
+		}
+	case row.Expiry+p.expire <= expiry:
+		if _, err = p.engine.Where(builder.Eq{"key": sid}).
+			Cols("data", "expiry").
+			Update(&models.SessionKey{Expiry: expiry}); err != nil {
+			return nil, err
+		}
+	default:
+		expiry = row.Expiry
+		data = row.Data
+	}
+
+	kv, err := decodeRow(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDBStore(p.engine, p.codec, sid, kv, expiry), nil
+}
+
+// Exist returns true if session with given ID exists and has not expired.
+func (p *DBProvider) Exist(sid string) bool {
+	var row models.SessionKey
+	has, err := p.engine.Where(builder.Eq{"key": sid}).Get(&row)
+	if err != nil {
+		log.Printf("session/db: error checking if session exists: %v", err)
+		return false
+	}
+
+	if !has {
+		return false
+	}
+
+	if row.Expiry+p.expire <= time.Now().Unix() {
+		if _, err := p.engine.Where(builder.Eq{"key": sid}).Delete(&models.SessionKey{}); err != nil {
+			log.Printf("session/db: error deleting expired session: %v", err)
+		}
+		return false
+	}
+
+	return true
+}
+
+// Destory deletes a session by session ID.
+func (p *DBProvider) Destory(sid string) error {
+	_, err := p.engine.Where(builder.Eq{"key": sid}).Delete(&models.SessionKey{})
+	return err
+}
+
+// Regenerate regenerates a session store from old session ID to new one.
+func (p *DBProvider) Regenerate(oldsid, sid string) (_ session.RawStore, err error) {
+	if p.Exist(sid) {
+		return nil, fmt.Errorf("new sid '%s' already exists", sid)
+	}
+
+	if !p.Exist(oldsid) {
+		if _, err = p.engine.Insert(&models.SessionKey{Key: oldsid, Expiry: time.Now().Unix()}); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err = p.engine.Where(builder.Eq{"key": oldsid}).
+		Cols("key").
+		Update(&models.SessionKey{Key: sid}); err != nil {
+		return nil, err
+	}
+
+	return p.Read(sid)
+}
+
+// Count counts and returns number of sessions.
+func (p *DBProvider) Count() (total int) {
+	count, err := p.engine.Count(&models.SessionKey{})
+	if err != nil {
+		panic("session/db: error counting records: " + err.Error())
+	}
+	return int(count)
+}
+
+// GC calls GC to clean expired sessions.
+func (p *DBProvider) GC() {
+	if _, err := p.engine.Where("expiry + ? <= ?", p.expire, time.Now().Unix()).
+		Delete(&models.SessionKey{}); err != nil {
+		log.Printf("session/db: error garbage collecting: %v", err)
+	}
+}
+
+func init() {
+	session.Register("db", &DBProvider{})
+}
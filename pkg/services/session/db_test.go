@@ -0,0 +1,92 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-xorm/builder"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestDBProviderReadWriteRoundTrip(t *testing.T) {
+	engine := sqlstore.InitTestDB(t)
+	p := &DBProvider{engine: engine, codec: gobCodec{}, expire: 60}
+
+	store, err := p.Read("sid")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if err := store.Set("uid", "42"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	store, err = p.Read("sid")
+	if err != nil {
+		t.Fatalf("Read after Release: %v", err)
+	}
+
+	if got := store.Get("uid"); got != "42" {
+		t.Fatalf("Get(uid) = %v, want 42", got)
+	}
+}
+
+func TestDBProviderReadExpiresStaleSessions(t *testing.T) {
+	engine := sqlstore.InitTestDB(t)
+	p := &DBProvider{engine: engine, codec: gobCodec{}, expire: 60}
+
+	store, err := p.Read("sid")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := store.Set("uid", "42"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	// Backdate the row past expiry so the next Read treats it as stale.
+	if _, err := engine.Where(builder.Eq{"key": "sid"}).
+		Cols("expiry").
+		Update(&models.SessionKey{Expiry: time.Now().Unix() - 120}); err != nil {
+		t.Fatalf("backdating expiry: %v", err)
+	}
+
+	store, err = p.Read("sid")
+	if err != nil {
+		t.Fatalf("Read after expiry: %v", err)
+	}
+
+	if got := store.Get("uid"); got != nil {
+		t.Fatalf("expected expired session data to be cleared, got %v", got)
+	}
+}
+
+func TestDBProviderExistTreatsExpiredRowAsAbsent(t *testing.T) {
+	engine := sqlstore.InitTestDB(t)
+	p := &DBProvider{engine: engine, codec: gobCodec{}, expire: 60}
+
+	if _, err := p.Read("sid"); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if !p.Exist("sid") {
+		t.Fatal("expected a freshly read session to exist")
+	}
+
+	if _, err := engine.Where(builder.Eq{"key": "sid"}).
+		Cols("expiry").
+		Update(&models.SessionKey{Expiry: time.Now().Unix() - 120}); err != nil {
+		t.Fatalf("backdating expiry: %v", err)
+	}
+
+	if p.Exist("sid") {
+		t.Fatal("expected an expired session to not exist")
+	}
+}
@@ -30,6 +30,7 @@ import (
 // MysqlStore represents a mysql session store implementation.
 type MysqlStore struct {
 	c      *sql.DB
+	codec  Codec
 	sid    string
 	lock   sync.RWMutex
 	data   map[interface{}]interface{}
@@ -38,9 +39,10 @@ type MysqlStore struct {
 }
 
 // NewMysqlStore creates and returns a mysql session store.
-func NewMysqlStore(c *sql.DB, sid string, kv map[interface{}]interface{}, expiry int64) *MysqlStore {
+func NewMysqlStore(c *sql.DB, codec Codec, sid string, kv map[interface{}]interface{}, expiry int64) *MysqlStore {
 	return &MysqlStore{
 		c:      c,
+		codec:  codec,
 		sid:    sid,
 		data:   kv,
 		expiry: expiry,
@@ -89,7 +91,7 @@ func (s *MysqlStore) Release() error {
 		return nil
 	}
 
-	data, err := session.EncodeGob(s.data)
+	data, err := encodeWithTag(s.codec, s.data)
 	if err != nil {
 		return err
 	}
@@ -115,14 +117,20 @@ func (s *MysqlStore) Flush() error {
 // MysqlProvider represents a mysql session provider implementation.
 type MysqlProvider struct {
 	c      *sql.DB
+	codec  Codec
 	expire int64
 }
 
 // Init initializes mysql session provider.
-// connStr: username:password@protocol(address)/dbname?param=value
+// connStr: username:password@protocol(address)/dbname?param=value[&codec=gob|json]
 func (p *MysqlProvider) Init(expire int64, connStr string) (err error) {
 	p.expire = expire
 
+	p.codec, connStr, err = parseCodec(connStr)
+	if err != nil {
+		return err
+	}
+
 	p.c, err = sql.Open("mysql", connStr)
 	p.c.SetConnMaxLifetime(time.Second * time.Duration(sessionConnMaxLifetime))
 	if err != nil {
@@ -131,33 +139,43 @@ func (p *MysqlProvider) Init(expire int64, connStr string) (err error) {
 	return p.c.Ping()
 }
 
-// Read returns raw session store by session ID.
+// Read returns raw session store by session ID. A row whose expiry has
+// already passed is reset in place and a fresh, empty store is returned
+// instead of resuming the stale session, closing the window between GC
+// cycles where an expired session ID would otherwise still be usable.
 func (p *MysqlProvider) Read(sid string) (session.RawStore, error) {
 	expiry := time.Now().Unix()
 	var data []byte
-	err := p.c.QueryRow("SELECT data,expiry FROM session WHERE `key`=?", sid).Scan(&data, &expiry)
-	if err == sql.ErrNoRows {
-		_, err = p.c.Exec("INSERT INTO session(`key`,data,expiry) VALUES(?,?,?)",
-			sid, "", expiry)
-	}
-	if err != nil {
+	var rowExpiry int64
+	err := p.c.QueryRow("SELECT data,expiry FROM session WHERE `key`=?", sid).Scan(&data, &rowExpiry)
+	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
 
-	var kv map[interface{}]interface{}
-	if len(data) == 0 {
-		kv = make(map[interface{}]interface{})
-	} else {
-		kv, err = session.DecodeGob(data)
-		if err != nil {
+	if err == sql.ErrNoRows {
+		if _, err = p.c.Exec("INSERT INTO session(`key`,data,expiry) VALUES(?,?,?)",
+			sid, "", expiry); err != nil {
+			return nil, err
+		}
+	} else if rowExpiry+p.expire <= expiry {
+		if _, err = p.c.Exec("UPDATE session SET data=?, expiry=? WHERE `key`=?",
+			"", expiry, sid); err != nil {
 			return nil, err
 		}
+		data = nil
+	} else {
+		expiry = rowExpiry
 	}
 
-	return NewMysqlStore(p.c, sid, kv, expiry), nil
+	kv, err := decodeRow(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMysqlStore(p.c, p.codec, sid, kv, expiry), nil
 }
 
-// Exist returns true if session with given ID exists.
+// Exist returns true if session with given ID exists and has not expired.
 func (p *MysqlProvider) Exist(sid string) bool {
 	exists, err := p.queryExists(sid)
 
@@ -175,13 +193,27 @@ func (p *MysqlProvider) Exist(sid string) bool {
 
 func (p *MysqlProvider) queryExists(sid string) (bool, error) {
 	var data []byte
-	err := p.c.QueryRow("SELECT data FROM session WHERE `key`=?", sid).Scan(&data)
+	var expiry int64
+	err := p.c.QueryRow("SELECT data,expiry FROM session WHERE `key`=?", sid).Scan(&data, &expiry)
 
 	if err != nil && err != sql.ErrNoRows {
 		return false, err
 	}
 
-	return err != sql.ErrNoRows, nil
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if expiry+p.expire <= time.Now().Unix() {
+		// Delete the expired row so a subsequent Regenerate doesn't collide
+		// with it on the `key` unique index.
+		if _, err := p.c.Exec("DELETE FROM session WHERE `key`=?", sid); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	return true, nil
 }
 
 // Destory deletes a session by session ID.
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMysqlProviderReadExpiresStaleSessions(t *testing.T) {
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		rowExists bool
+		rowExpiry int64
+		wantReset bool
+	}{
+		{name: "missing row inserts a fresh session", rowExists: false},
+		{name: "live row is returned as-is", rowExists: true, rowExpiry: now},
+		{name: "expired row is reset to a fresh session", rowExists: true, rowExpiry: now - 120, wantReset: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New: %v", err)
+			}
+			defer db.Close()
+
+			p := &MysqlProvider{c: db, codec: gobCodec{}, expire: 60}
+
+			rows := sqlmock.NewRows([]string{"data", "expiry"})
+			if tt.rowExists {
+				rows = rows.AddRow([]byte(nil), tt.rowExpiry)
+			}
+			mock.ExpectQuery(`SELECT data,expiry FROM session WHERE`).
+				WithArgs("sid").
+				WillReturnRows(rows)
+
+			switch {
+			case !tt.rowExists:
+				mock.ExpectExec(`INSERT INTO session`).
+					WithArgs("sid", "", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			case tt.wantReset:
+				mock.ExpectExec(`UPDATE session SET data=., expiry=. WHERE`).
+					WithArgs("", sqlmock.AnyArg(), "sid").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			store, err := p.Read("sid")
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+
+			ms, ok := store.(*MysqlStore)
+			if !ok {
+				t.Fatalf("Read returned %T, want *MysqlStore", store)
+			}
+
+			if tt.wantReset && len(ms.data) != 0 {
+				t.Fatalf("expected expired session data to be cleared, got %#v", ms.data)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestMysqlProviderQueryExistsTreatsExpiredRowAsAbsent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	p := &MysqlProvider{c: db, codec: gobCodec{}, expire: 60}
+
+	mock.ExpectQuery(`SELECT data,expiry FROM session WHERE`).
+		WithArgs("sid").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "expiry"}).AddRow([]byte(nil), time.Now().Unix()-120))
+	mock.ExpectExec(`DELETE FROM session WHERE`).
+		WithArgs("sid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	exists, err := p.queryExists("sid")
+	if err != nil {
+		t.Fatalf("queryExists: %v", err)
+	}
+
+	if exists {
+		t.Fatal("expected an expired row to be reported as non-existent")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
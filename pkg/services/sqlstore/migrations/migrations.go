@@ -0,0 +1,9 @@
+package migrations
+
+import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+// AddMigrations registers every migration that needs to run against
+// Grafana's database, in order.
+func AddMigrations(mg *Migrator) {
+	addSessionKeyMigrations(mg)
+}
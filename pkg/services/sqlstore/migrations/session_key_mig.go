@@ -0,0 +1,24 @@
+package migrations
+
+import . "github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+
+// addSessionKeyMigrations creates the session_key table used by the "db"
+// session provider, so web sessions are created and upgraded alongside the
+// rest of the schema on every supported database engine.
+func addSessionKeyMigrations(mg *Migrator) {
+	sessionKeyV1 := Table{
+		Name: "session_key",
+		Columns: []*Column{
+			{Name: "id", Type: DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "key", Type: DB_NVarchar, Length: 255, Nullable: false},
+			{Name: "data", Type: DB_Blob, Nullable: true},
+			{Name: "expiry", Type: DB_BigInt, Nullable: false},
+		},
+		Indices: []*Index{
+			{Cols: []string{"key"}, Type: UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create session_key table", NewAddTableMigration(sessionKeyV1))
+	mg.AddMigration("add unique index session_key.key", NewAddIndexMigration(sessionKeyV1, sessionKeyV1.Indices[0]))
+}